@@ -9,27 +9,159 @@ import (
 	"math"
 	"math/rand"
 	"net/http"
+	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/psmason/3body/stream"
+)
+
+// transport names recognized by requestHandler's ?transport= query param.
+const (
+	transportFFmpeg    = "ffmpeg"
+	transportMJPEG     = "mjpeg"
+	transportWebSocket = "websocket"
 )
 
 const (
 	greenIndex = 1
-	size       = 800
-	g          = 1    // gravitational constant
-	m          = 1E7  // same mass for all particles
+
+	// collisionRadius is the collision radius of a unit-mass (m) particle;
+	// heavier particles scale it by the cube root of their relative mass.
+	collisionRadius = 6.0
+
+	// defaultParticleCount is how many bodies defaultScenario generates.
+	defaultParticleCount = 3
+)
+
+// g, m, epoch, size and drawRadius were once hardcoded constants; they're
+// now defaults that a loaded Scenario can override. See scenario.go.
+var (
+	g          = 1.0  // gravitational constant
+	m          = 1E7  // default particle mass
 	epoch      = 1E-5 // simulation epoch
-	count      = 3    // number of particles
-	drawRadius = 8
+	size       = 800.0
+	drawRadius = 8.0
 )
 
+// collisionMode selects how overlapping particles are resolved each step.
+type collisionMode int
+
+const (
+	collisionNone collisionMode = iota
+	collisionElastic
+	collisionMerge
+)
+
+// activeCollisionMode is the collision behavior used by nBody.
+var activeCollisionMode = collisionElastic
+
+// stats exposes a running count of active vs. merged bodies.
+var stats simulationStats
+
+type simulationStats struct {
+	mu     sync.Mutex
+	active int
+	merged int
+}
+
+func (s *simulationStats) setActive(active int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = active
+}
+
+func (s *simulationStats) recordMerge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.merged++
+}
+
+func (s *simulationStats) get() (active, merged int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, s.merged
+}
+
 func main() {
 	rand.Seed(time.Now().UTC().UnixNano())
+
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		if err := runSimulate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	go runSimulation(world)
 	http.HandleFunc("/3body", requestHandler)
+	http.HandleFunc("/3body/ui", uiHandler)
+	http.HandleFunc("/3body/spawn", spawnHandler)
+	http.HandleFunc("/3body/clear", clearHandler)
+	http.HandleFunc("/3body/scenario", scenarioHandler)
+	http.HandleFunc("/3body/stats", statsHandler)
 	log.Fatal(http.ListenAndServe("localhost:8000", nil))
 }
 
+// frameWriter is anything drawParticles can push a rendered frame to.
+type frameWriter interface {
+	WriteFrame(img image.Image) error
+}
+
+// ffmpegFrameWriter adapts ffmpeg's stdin pipe, which expects a raw sequence
+// of JPEG-encoded frames, to the frameWriter interface.
+type ffmpegFrameWriter struct {
+	w io.Writer
+}
+
+func (f ffmpegFrameWriter) WriteFrame(img image.Image) error {
+	return jpeg.Encode(f.w, img, nil)
+}
+
 func requestHandler(w http.ResponseWriter, r *http.Request) {
+	switch chooseTransport(r) {
+	case transportMJPEG:
+		nBody(stream.NewMJPEGWriter(w))
+	case transportWebSocket:
+		fw, err := stream.NewWebSocketFrameWriter(w, r)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		defer fw.Close()
+		nBody(fw)
+	default:
+		nBody(ffmpegFrameWriter{w: ffmpegPipe(w)})
+	}
+}
+
+// chooseTransport picks a streaming transport from the ?transport= query
+// param, falling back to the Accept header and finally the legacy ffmpeg
+// pipe. The query param is the only selector browsers can realistically hit:
+// a browser's Accept header is a comma-separated list of content types it's
+// willing to accept (e.g. "text/html,application/xhtml+xml,*/*;q=0.8"), not
+// a single exact value, so the fallback matches on substring rather than
+// equality.
+func chooseTransport(r *http.Request) string {
+	if t := r.URL.Query().Get("transport"); t != "" {
+		return t
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "multipart/x-mixed-replace"):
+		return transportMJPEG
+	case strings.Contains(accept, "application/octet-stream"):
+		return transportWebSocket
+	default:
+		return transportFFmpeg
+	}
+}
+
+// ffmpegPipe shells out to ffmpeg to transcode a raw JPEG frame sequence
+// into ogg video, writing the result directly to w.
+func ffmpegPipe(w http.ResponseWriter) io.WriteCloser {
 	cmd := exec.Command("ffmpeg",
 		"-f", "image2pipe",
 		"-pix_fmt", "yuv420p",
@@ -43,11 +175,10 @@ func requestHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = cmd.Start()
-	if err != nil {
+	if err := cmd.Start(); err != nil {
 		log.Fatal(err)
 	}
-	nBody(stdin)
+	return stdin
 }
 
 type particle struct {
@@ -61,16 +192,10 @@ type force struct {
 	x, y float64
 }
 
-func newParticle() particle {
-	return particle{
-		mass:          m,
-		xPosition:     rand.NormFloat64() * size / 6,
-		yPosition:     rand.NormFloat64() * size / 6,
-		xVelocity:     0.0,
-		yVelocity:     0.0,
-		xAcceleration: 0.0,
-		yAcceleration: 0.0,
-	}
+// radius is the particle's physical radius, derived from its mass so that
+// density stays constant: r ∝ m^(1/3).
+func (p *particle) radius() float64 {
+	return collisionRadius * math.Cbrt(p.mass/m)
 }
 
 func (p *particle) distanceSquared(o *particle) float64 {
@@ -79,52 +204,105 @@ func (p *particle) distanceSquared(o *particle) float64 {
 	return dx*dx + dy*dy
 }
 
-func (p *particle) forceActedOnBy(o *particle) force {
-	d := p.distanceSquared(o)
-	if d == 0 {
-		// the same particle
-		return force{}
+// Mass and Position satisfy barneshut.Body, letting computeForces hand
+// particles straight to a Solver.
+func (p *particle) Mass() float64                { return p.mass }
+func (p *particle) Position() (float64, float64) { return p.xPosition, p.yPosition }
+
+// elasticCollide resolves a collision between p and o by projecting both
+// velocities onto the collision normal and swapping the normal components
+// according to the standard 1D elastic collision equations for unequal
+// masses; the tangential components are left untouched.
+func elasticCollide(p, o *particle) {
+	dx := o.xPosition - p.xPosition
+	dy := o.yPosition - p.yPosition
+	dist := math.Sqrt(dx*dx + dy*dy)
+	if dist == 0 {
+		return
 	}
+	nx, ny := dx/dist, dy/dist
+
+	pn := p.xVelocity*nx + p.yVelocity*ny
+	on := o.xVelocity*nx + o.yVelocity*ny
 
-	c := g * p.mass * o.mass / (d*math.Sqrt(d) + /* softening */ 1E6)
-	return force{
-		x: c * (o.xPosition - p.xPosition),
-		y: c * (o.yPosition - p.yPosition),
+	// Already separating along the normal: resolving again would push an
+	// overlapping-but-receding pair back together, so there's nothing to do.
+	if on-pn >= 0 {
+		return
 	}
+
+	totalMass := p.mass + o.mass
+	pnAfter := (pn*(p.mass-o.mass) + 2*o.mass*on) / totalMass
+	onAfter := (on*(o.mass-p.mass) + 2*p.mass*pn) / totalMass
+
+	p.xVelocity += (pnAfter - pn) * nx
+	p.yVelocity += (pnAfter - pn) * ny
+	o.xVelocity += (onAfter - on) * nx
+	o.yVelocity += (onAfter - on) * ny
 }
 
-func (p *particle) totalForceActedOnBy(particles []particle) force {
-	totalForce := force{}
-	for _, o := range particles {
-		partialForce := p.forceActedOnBy(&o)
-		totalForce.x += partialForce.x
-		totalForce.y += partialForce.y
+// mergeParticles combines p and o into a single particle, conserving total
+// mass, momentum (mv) and center-of-mass position.
+func mergeParticles(p, o *particle) particle {
+	totalMass := p.mass + o.mass
+	return particle{
+		mass:      totalMass,
+		xPosition: (p.xPosition*p.mass + o.xPosition*o.mass) / totalMass,
+		yPosition: (p.yPosition*p.mass + o.yPosition*o.mass) / totalMass,
+		xVelocity: (p.xVelocity*p.mass + o.xVelocity*o.mass) / totalMass,
+		yVelocity: (p.yVelocity*p.mass + o.yVelocity*o.mass) / totalMass,
 	}
-	return totalForce
 }
 
-func (p *particle) update(f force) particle {
-	// leapfrog integration
-	// https://en.wikipedia.org/wiki/Leapfrog_integration
-	xVelocity := p.xVelocity + epoch*0.5*p.xAcceleration
-	yVelocity := p.yVelocity + epoch*0.5*p.yAcceleration
-	xPosition := p.xPosition + epoch*xVelocity
-	yPosition := p.yPosition + epoch*yVelocity
-	xVelocity = p.xVelocity + epoch*0.5*f.x
-	yVelocity = p.yVelocity + epoch*0.5*f.y
-	return particle{
-		mass:          p.mass,
-		xPosition:     xPosition,
-		yPosition:     yPosition,
-		xVelocity:     xVelocity,
-		yVelocity:     yVelocity,
-		xAcceleration: f.x,
-		yAcceleration: f.y,
+// resolveCollisions runs an O(N²) pairwise check over particles and applies
+// activeCollisionMode to any pair whose separation has dropped below the sum
+// of their radii. Particles that merge have their leapfrog acceleration
+// re-seeded from the survivors of this step.
+func resolveCollisions(particles []particle) []particle {
+	if activeCollisionMode == collisionNone {
+		return particles
 	}
+
+	merged := make([]bool, len(particles))
+	survivors := particles[:0:0]
+	for i := range particles {
+		if merged[i] {
+			continue
+		}
+		p := particles[i]
+		for j := i + 1; j < len(particles); j++ {
+			if merged[j] {
+				continue
+			}
+			o := &particles[j]
+			if p.distanceSquared(o) >= (p.radius()+o.radius())*(p.radius()+o.radius()) {
+				continue
+			}
+
+			switch activeCollisionMode {
+			case collisionElastic:
+				elasticCollide(&p, o)
+			case collisionMerge:
+				p = mergeParticles(&p, o)
+				merged[j] = true
+				stats.recordMerge()
+			}
+		}
+		survivors = append(survivors, p)
+	}
+
+	if activeCollisionMode == collisionMerge {
+		for i, f := range computeForces(survivors) {
+			survivors[i].xAcceleration = f.x
+			survivors[i].yAcceleration = f.y
+		}
+	}
+
+	return survivors
 }
 
 type animator struct {
-	writer              io.WriteCloser
+	writer              frameWriter
 	particleGenerations []particleGeneration
 }
 
@@ -133,17 +311,21 @@ type particleGeneration struct {
 	c uint8
 }
 
-func (a *animator) drawCircle(img *image.Paletted, p particle, r int, c uint8) {
+func (a *animator) drawCircle(img *image.Paletted, p particle, r int, c uint8, size float64) {
 	for x := -r; x < r; x++ {
 		for y := -r; y < r; y++ {
 			if x*x+y*y < r*r {
-				img.SetColorIndex(size/2+int(p.xPosition)+x, size/2+int(p.yPosition)+y, c)
+				img.SetColorIndex(int(size)/2+int(p.xPosition)+x, int(size)/2+int(p.yPosition)+y, c)
 			}
 		}
 	}
 }
 
-func (a *animator) drawParticles(particles []particle) {
+// drawParticles renders particles and field into a frame and writes it.
+// size, drawRadius and fieldMode are passed in by the caller (from
+// World.renderParams) rather than read as globals, since this runs in a
+// per-connection goroutine that doesn't otherwise hold w.mu.
+func (a *animator) drawParticles(particles []particle, field Field, size, drawRadius float64, fieldMode bool) error {
 	// update existing generations
 	pruneIndex := -1
 	for i, p := range a.particleGenerations {
@@ -160,52 +342,67 @@ func (a *animator) drawParticles(particles []particle) {
 		a.particleGenerations = append(a.particleGenerations, particleGeneration{p: p, c: 8})
 	}
 
-	img := image.NewPaletted(image.Rect(0, 0, size, size),
-		[]color.Color{
-			color.Gray{0xff},
-			color.Gray{0xdf},
-			color.Gray{0xbf},
-			color.Gray{0x9f},
-			color.Gray{0x7f},
-			color.Gray{0x5f},
-			color.Gray{0x3f},
-			color.Gray{0x1f},
-			color.Gray{0x00},
-		})
+	palette := []color.Color{
+		color.Gray{0xff},
+		color.Gray{0xdf},
+		color.Gray{0xbf},
+		color.Gray{0x9f},
+		color.Gray{0x7f},
+		color.Gray{0x5f},
+		color.Gray{0x3f},
+		color.Gray{0x1f},
+		color.Gray{0x00},
+	}
+	fieldOffset := len(palette)
+	if fieldMode {
+		palette = append(palette, fieldPalette...)
+	}
+	img := image.NewPaletted(image.Rect(0, 0, int(size), int(size)), palette)
+
+	if fieldMode {
+		a.drawField(img, field, fieldOffset, size)
+	}
 	for _, p := range a.particleGenerations {
-		a.drawCircle(img, p.p, drawRadius, p.c)
+		r := int(drawRadius * math.Cbrt(p.p.mass/m))
+		a.drawCircle(img, p.p, r, p.c, size)
 	}
 
-	jpeg.Encode(a.writer, img, nil)
+	return a.writer.WriteFrame(img)
 }
 
-func nBody(writer io.WriteCloser) {
-	// https://en.wikipedia.org/wiki/N-body_problem
-	// two dimensions only
-
-	particles := []particle{}
-	for i := 0; i < count; i++ {
-		particles = append(particles, newParticle())
-	}
-
-	// leapfrog initial accelerations
-	for _, p := range particles {
-		totalForce := p.totalForceActedOnBy(particles)
-		p.xAcceleration = totalForce.x
-		p.yAcceleration = totalForce.y
+// drawField rasterizes field under the particles, one block of pixels per
+// grid cell, using palette entries starting at fieldOffset.
+func (a *animator) drawField(img *image.Paletted, field Field, fieldOffset int, size float64) {
+	cellSize := int(size) / fieldResolution
+	for i := 0; i < fieldResolution; i++ {
+		for j := 0; j < fieldResolution; j++ {
+			b := field.bucket(i, j)
+			if b < 0 {
+				continue
+			}
+			for dx := 0; dx < cellSize; dx++ {
+				for dy := 0; dy < cellSize; dy++ {
+					img.SetColorIndex(i*cellSize+dx, j*cellSize+dy, uint8(fieldOffset+b))
+				}
+			}
+		}
 	}
+}
 
-	a := animator{
-		writer: writer,
-	}
-	for {
-		a.drawParticles(particles)
+// frameRate is how often a viewer connection renders a frame from the
+// shared world; it's independent of the simulation's own step rate.
+const frameRate = 24
 
-		updated := []particle{}
-		for _, p := range particles {
-			totalForce := p.totalForceActedOnBy(particles)
-			updated = append(updated, p.update(totalForce))
+// nBody renders the shared world's live particles to writer at frameRate,
+// until the connection errors out (e.g. the viewer disconnected).
+func nBody(writer frameWriter) {
+	a := animator{writer: writer}
+	ticker := time.NewTicker(time.Second / frameRate)
+	defer ticker.Stop()
+	for range ticker.C {
+		sz, radius, fieldOn := world.renderParams()
+		if err := a.drawParticles(world.snapshot(), world.fieldSnapshot(), sz, radius, fieldOn); err != nil {
+			return
 		}
-		particles = updated
 	}
 }
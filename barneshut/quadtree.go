@@ -0,0 +1,207 @@
+package barneshut
+
+import "math"
+
+// BarnesHut approximates forces by walking a quadtree: distant clusters of
+// bodies are treated as a single mass at their center of mass, controlled by
+// Theta (the standard Barnes-Hut s/d accuracy threshold).
+type BarnesHut struct {
+	G         float64
+	Softening float64
+	Theta     float64 // defaults to 0.5 when zero
+}
+
+// Forces implements Solver.
+func (bh BarnesHut) Forces(bodies []Body) []Force {
+	if len(bodies) == 0 {
+		return nil
+	}
+
+	root := buildTree(bodies)
+	forces := make([]Force, len(bodies))
+	for i, b := range bodies {
+		forces[i] = root.forceOn(b, bh.G, bh.Softening, bh.theta(), nil)
+	}
+	return forces
+}
+
+// Prepare implements Preparer: the quadtree only needs to be built once, then
+// ForceOn can walk it for as many queries as an integrator needs.
+func (bh BarnesHut) Prepare(bodies []Body) Prepared {
+	if len(bodies) == 0 {
+		return treePrepared{}
+	}
+	return treePrepared{root: buildTree(bodies), g: bh.G, softening: bh.Softening, theta: bh.theta()}
+}
+
+func (bh BarnesHut) theta() float64 {
+	if bh.Theta == 0 {
+		return 0.5
+	}
+	return bh.Theta
+}
+
+// treePrepared answers ForceOn against the quadtree BarnesHut.Prepare already
+// built, so a step's worth of substep queries cost one tree build plus one
+// walk per query instead of one build per query.
+type treePrepared struct {
+	root                *node
+	g, softening, theta float64
+}
+
+func (p treePrepared) ForceOn(b Body, exclude Body) Force {
+	return p.root.forceOn(b, p.g, p.softening, p.theta, exclude)
+}
+
+// node is a square region of a quadtree. An empty node has mass == 0. A node
+// with body != nil is an external (leaf) node holding exactly one body.
+// Any other node with mass > 0 is internal and its force contribution comes
+// from its four children.
+type node struct {
+	cx, cy, half float64 // bounding square: center and half side length
+	mass         float64
+	comX, comY   float64
+	body         Body
+	children     [4]*node
+}
+
+// minHalf bounds how far the tree subdivides; bodies that are still
+// distinct past this resolution are folded into the same aggregate rather
+// than recursing forever.
+const minHalf = 1e-6
+
+func newNode(cx, cy, half float64) *node {
+	return &node{cx: cx, cy: cy, half: half}
+}
+
+func (n *node) isExternal() bool {
+	return n.children[0] == nil && n.children[1] == nil && n.children[2] == nil && n.children[3] == nil
+}
+
+// insert adds b to the subtree rooted at n, updating n's running
+// center-of-mass as it goes: com = (com*m_old + p.pos*p.m) / (m_old + p.m).
+func (n *node) insert(b Body) {
+	bx, by := b.Position()
+	bm := b.Mass()
+
+	if n.mass == 0 {
+		n.body = b
+		n.mass = bm
+		n.comX, n.comY = bx, by
+		return
+	}
+
+	if n.body != nil {
+		existing := n.body
+		n.body = nil
+		n.insertChild(existing)
+	}
+
+	n.comX = (n.comX*n.mass + bx*bm) / (n.mass + bm)
+	n.comY = (n.comY*n.mass + by*bm) / (n.mass + bm)
+	n.mass += bm
+	n.insertChild(b)
+}
+
+func (n *node) insertChild(b Body) {
+	if n.half < minHalf {
+		// bodies are numerically coincident at this resolution; stop
+		// subdividing and let the parent's aggregate mass/COM stand in for
+		// both.
+		return
+	}
+
+	bx, by := b.Position()
+	idx := quadrant(n.cx, n.cy, bx, by)
+	if n.children[idx] == nil {
+		ccx, ccy := childCenter(n.cx, n.cy, n.half, idx)
+		n.children[idx] = newNode(ccx, ccy, n.half/2)
+	}
+	n.children[idx].insert(b)
+}
+
+// quadrant returns which of a node's four children (x, y) falls into.
+func quadrant(cx, cy, x, y float64) int {
+	switch {
+	case x >= cx && y >= cy:
+		return 0
+	case x < cx && y >= cy:
+		return 1
+	case x < cx && y < cy:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func childCenter(cx, cy, half float64, idx int) (float64, float64) {
+	q := half / 2
+	switch idx {
+	case 0:
+		return cx + q, cy + q
+	case 1:
+		return cx - q, cy + q
+	case 2:
+		return cx - q, cy - q
+	default:
+		return cx + q, cy - q
+	}
+}
+
+// buildTree computes the bounding square of bodies and inserts each into a
+// fresh quadtree.
+func buildTree(bodies []Body) *node {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, b := range bodies {
+		x, y := b.Position()
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	half := math.Max(maxX-minX, maxY-minY)/2 + 1
+	root := newNode((minX+maxX)/2, (minY+maxY)/2, half)
+	for _, b := range bodies {
+		root.insert(b)
+	}
+	return root
+}
+
+// forceOn computes the force n exerts on b, recursing into children only
+// when n's side-length-over-distance ratio exceeds theta. exclude, if
+// non-nil, is a body to treat as absent — used when b is a hypothetical
+// stand-in for exclude itself, so exclude's own (pre-step) position doesn't
+// exert a spurious force on it.
+func (n *node) forceOn(b Body, g, softening, theta float64, exclude Body) Force {
+	if n == nil || n.mass == 0 {
+		return Force{}
+	}
+
+	bx, by := b.Position()
+	if n.isExternal() {
+		// n.body is nil both for an empty node (already handled above) and
+		// for a cluster of bodies collapsed together by insertChild's
+		// minHalf cutoff; only a genuine single-body leaf can match
+		// exclude, so a nil body must never short-circuit here even when
+		// exclude is also nil (the Forces path), or a collapsed cluster
+		// would wrongly exert no gravity on anyone.
+		if n.body != nil && n.body == exclude {
+			return Force{}
+		}
+		return softenedForce(g, b.Mass(), bx, by, n.mass, n.comX, n.comY, softening)
+	}
+
+	dx, dy := n.comX-bx, n.comY-by
+	d := math.Sqrt(dx*dx + dy*dy)
+	if d == 0 || n.half*2/d < theta {
+		return softenedForce(g, b.Mass(), bx, by, n.mass, n.comX, n.comY, softening)
+	}
+
+	var f Force
+	for _, c := range n.children {
+		cf := c.forceOn(b, g, softening, theta, exclude)
+		f.X += cf.X
+		f.Y += cf.Y
+	}
+	return f
+}
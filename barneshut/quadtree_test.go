@@ -0,0 +1,26 @@
+package barneshut
+
+import "testing"
+
+type testBody struct{ m, x, y float64 }
+
+func (b testBody) Mass() float64                { return b.m }
+func (b testBody) Position() (float64, float64) { return b.x, b.y }
+
+// TestCollapsedClusterStillExertsGravity guards against insertChild's
+// minHalf cutoff producing a node that's external (no children) but holds
+// an aggregate of bodies rather than a single one (body == nil). forceOn
+// must not mistake that nil body for a match against a nil exclude, or the
+// whole cluster's mass silently stops exerting gravity on anyone.
+func TestCollapsedClusterStillExertsGravity(t *testing.T) {
+	cluster := &node{cx: 0, cy: 0, half: 1, mass: 2, comX: 0, comY: 0}
+	if !cluster.isExternal() {
+		t.Fatal("expected a childless node to be external")
+	}
+
+	probe := testBody{m: 1, x: 1000, y: 0}
+	f := cluster.forceOn(probe, 1, 0, 0.5, nil)
+	if f.X == 0 && f.Y == 0 {
+		t.Fatalf("collapsed cluster exerted no force on a distant probe: %+v", f)
+	}
+}
@@ -0,0 +1,107 @@
+// Package barneshut computes gravitational forces between point masses,
+// either by direct pairwise summation or by the Barnes-Hut quadtree
+// approximation.
+package barneshut
+
+import "math"
+
+// Body is a point mass a Solver can compute forces between.
+type Body interface {
+	Mass() float64
+	Position() (x, y float64)
+}
+
+// Force is the net force acting on a body.
+type Force struct {
+	X, Y float64
+}
+
+// Solver computes the net force on every body in bodies, in the same order.
+type Solver interface {
+	Forces(bodies []Body) []Force
+}
+
+// Preparer builds a Prepared solve over a fixed set of bodies once, so that
+// many ForceOn queries against hypothetical positions (as an integrator
+// substep needs) don't each pay the cost of resolving the whole system
+// again.
+type Preparer interface {
+	Prepare(bodies []Body) Prepared
+}
+
+// Prepared is a force solve already built from a fixed set of bodies. ForceOn
+// answers what force those bodies exert on b, treating exclude (one of the
+// bodies Prepare was built from, or nil) as absent — the caller uses this to
+// ask what force the rest of the system exerts on a hypothetical position of
+// the body it's currently integrating.
+type Prepared interface {
+	ForceOn(b Body, exclude Body) Force
+}
+
+// Direct computes forces by direct O(N²) pairwise summation.
+type Direct struct {
+	G         float64
+	Softening float64
+}
+
+// Forces implements Solver.
+func (d Direct) Forces(bodies []Body) []Force {
+	forces := make([]Force, len(bodies))
+	for i, b := range bodies {
+		bx, by := b.Position()
+		for j, o := range bodies {
+			if i == j {
+				continue
+			}
+			ox, oy := o.Position()
+			f := softenedForce(d.G, b.Mass(), bx, by, o.Mass(), ox, oy, d.Softening)
+			forces[i].X += f.X
+			forces[i].Y += f.Y
+		}
+	}
+	return forces
+}
+
+// Prepare implements Preparer.
+func (d Direct) Prepare(bodies []Body) Prepared {
+	prepared := make([]Body, len(bodies))
+	copy(prepared, bodies)
+	return directPrepared{bodies: prepared, g: d.G, softening: d.Softening}
+}
+
+// directPrepared answers ForceOn by summing over the bodies Direct.Prepare
+// was given, which costs the same O(N) per query as Direct.Forces spends per
+// body — Prepare exists so that cost is paid once per query rather than
+// N times over, not to make Direct itself any cheaper.
+type directPrepared struct {
+	bodies       []Body
+	g, softening float64
+}
+
+func (p directPrepared) ForceOn(b Body, exclude Body) Force {
+	bx, by := b.Position()
+	var f Force
+	for _, o := range p.bodies {
+		if o == exclude {
+			continue
+		}
+		ox, oy := o.Position()
+		c := softenedForce(p.g, b.Mass(), bx, by, o.Mass(), ox, oy, p.softening)
+		f.X += c.X
+		f.Y += c.Y
+	}
+	return f
+}
+
+// softenedForce is the softened inverse-square gravitational force exerted
+// on a mass at (xA, yA) by a mass at (xB, yB).
+func softenedForce(g, massA, xA, yA, massB, xB, yB, softening float64) Force {
+	dx, dy := xB-xA, yB-yA
+	d2 := dx*dx + dy*dy
+	if d2 == 0 {
+		return Force{}
+	}
+
+	c := g * massA * massB / (d2*math.Sqrt(d2) + softening)
+	return Force{X: c * dx, Y: c * dy}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// runSimulate implements the `3body simulate` subcommand: a headless run
+// that steps a Scenario a fixed number of times and transcodes the rendered
+// frames straight to a video file via ffmpeg, with no HTTP server involved.
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	scenarioPath := fs.String("scenario", "", "path to a JSON or YAML scenario file")
+	frames := fs.Int("frames", 1000, "number of frames to render")
+	out := fs.String("out", "run.mp4", "output video path")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *scenarioPath == "" {
+		return fmt.Errorf("simulate: -scenario is required")
+	}
+
+	scenario, err := LoadScenarioFile(*scenarioPath)
+	if err != nil {
+		return err
+	}
+
+	w := &World{}
+	if err := scenario.apply(w); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("ffmpeg", "-y", "-f", "image2pipe", "-r", "24", "-i", "-", *out)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	a := animator{writer: ffmpegFrameWriter{w: stdin}}
+	for i := 0; i < *frames; i++ {
+		w.step()
+		sz, radius, fieldOn := w.renderParams()
+		if err := a.drawParticles(w.snapshot(), w.fieldSnapshot(), sz, radius, fieldOn); err != nil {
+			stdin.Close()
+			return err
+		}
+	}
+	stdin.Close()
+	return cmd.Wait()
+}
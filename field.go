@@ -0,0 +1,107 @@
+package main
+
+import "image/color"
+
+// fieldResolution is the number of grid cells along each axis of the scent
+// field; particle coordinates in [-size/2, size/2] are mapped onto it.
+const fieldResolution = 200
+
+// fieldDecay is the multiplicative per-step decay applied to every cell, so
+// deposits fade into trails rather than accumulating forever.
+const fieldDecay = 0.97
+
+// fieldDepositAmount is how much a particle adds to the field cell under it
+// each step.
+const fieldDepositAmount = 1.0
+
+// fieldMode switches the simulation from pure gravity to additionally
+// depositing into, and steering particles by, the scent field — enabling
+// flocking/slime-mold-style agent behaviors. Off by default so gravity
+// remains what the 3-body mode boots into.
+var fieldMode = false
+
+// fieldSteerStrength scales how strongly a particle's velocity is nudged
+// toward the field's gradient each step when fieldMode is enabled.
+var fieldSteerStrength = 0.0
+
+// Field is a scalar grid that particles deposit scent/pheromone into and
+// decays multiplicatively over time, turning the fading-generation look of
+// the animator into something particles can also read and react to.
+type Field struct {
+	cells [fieldResolution][fieldResolution]float32
+}
+
+// Decay multiplies every cell by fieldDecay.
+func (f *Field) Decay() {
+	for i := range f.cells {
+		for j := range f.cells[i] {
+			f.cells[i][j] *= fieldDecay
+		}
+	}
+}
+
+// Deposit adds amount to the cell nearest world coordinates (x, y).
+func (f *Field) Deposit(x, y float64, amount float32) {
+	i, j, ok := f.index(x, y)
+	if !ok {
+		return
+	}
+	f.cells[i][j] += amount
+}
+
+// Gradient estimates the field's gradient at (x, y) via a central
+// difference, for steering particle accelerations toward higher
+// concentration.
+func (f *Field) Gradient(x, y float64) (dx, dy float64) {
+	i, j, ok := f.index(x, y)
+	if !ok {
+		return 0, 0
+	}
+	return float64(f.at(i+1, j) - f.at(i-1, j)), float64(f.at(i, j+1) - f.at(i, j-1))
+}
+
+func (f *Field) at(i, j int) float32 {
+	if i < 0 || i >= fieldResolution || j < 0 || j >= fieldResolution {
+		return 0
+	}
+	return f.cells[i][j]
+}
+
+// index maps a world coordinate in [-size/2, size/2] to a grid cell.
+func (f *Field) index(x, y float64) (i, j int, ok bool) {
+	i = int((x + size/2) / size * fieldResolution)
+	j = int((y + size/2) / size * fieldResolution)
+	if i < 0 || i >= fieldResolution || j < 0 || j >= fieldResolution {
+		return 0, 0, false
+	}
+	return i, j, true
+}
+
+// fieldPalette is a small viridis-like perceptual ramp the animator draws
+// field intensity with, from faint to saturated.
+var fieldPalette = []color.Color{
+	color.RGBA{0x44, 0x01, 0x54, 0xff},
+	color.RGBA{0x3b, 0x52, 0x8b, 0xff},
+	color.RGBA{0x21, 0x90, 0x8c, 0xff},
+	color.RGBA{0x5d, 0xc9, 0x63, 0xff},
+	color.RGBA{0xfd, 0xe7, 0x25, 0xff},
+}
+
+// bucket maps cell (i, j)'s intensity to an index into fieldPalette, or -1
+// if the cell is empty and shouldn't be drawn at all.
+func (f *Field) bucket(i, j int) int {
+	switch v := f.cells[i][j]; {
+	case v <= 0:
+		return -1
+	case v < 1:
+		return 0
+	case v < 2:
+		return 1
+	case v < 4:
+		return 2
+	case v < 8:
+		return 3
+	default:
+		return 4
+	}
+}
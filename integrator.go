@@ -0,0 +1,135 @@
+package main
+
+import "github.com/psmason/3body/barneshut"
+
+// ForceFunc evaluates the net force on a hypothetical particle state,
+// holding every other particle fixed — the approximation every integrator
+// below makes when it needs to re-evaluate forces at an intermediate
+// sub-step.
+type ForceFunc func(p particle) force
+
+// Integrator advances a particle by one step of size dt.
+type Integrator interface {
+	Step(p particle, dt float64, f ForceFunc) particle
+}
+
+// activeIntegrator is the integration scheme used by World.step.
+var activeIntegrator Integrator = VelocityVerlet{}
+
+// forceFuncFor builds the ForceFunc for self, querying prepared (built once
+// per step by prepareForces) rather than re-resolving the whole system —
+// Direct and Barnes-Hut alike answer a ForceOn query far more cheaply than
+// rebuilding their solve from scratch, which matters since VelocityVerlet
+// calls this once per particle per step and RK4 calls it four times.
+func forceFuncFor(prepared barneshut.Prepared, self barneshut.Body) ForceFunc {
+	return func(candidate particle) force {
+		f := prepared.ForceOn(&candidate, self)
+		return force{x: f.X, y: f.Y}
+	}
+}
+
+// SymplecticEuler is a first-order symplectic integrator: velocity is
+// updated from the current force, then position from the new velocity.
+type SymplecticEuler struct{}
+
+func (SymplecticEuler) Step(p particle, dt float64, f ForceFunc) particle {
+	force := f(p)
+	ax := force.x / p.mass
+	ay := force.y / p.mass
+
+	xVelocity := p.xVelocity + dt*ax
+	yVelocity := p.yVelocity + dt*ay
+	return particle{
+		mass:          p.mass,
+		xPosition:     p.xPosition + dt*xVelocity,
+		yPosition:     p.yPosition + dt*yVelocity,
+		xVelocity:     xVelocity,
+		yVelocity:     yVelocity,
+		xAcceleration: ax,
+		yAcceleration: ay,
+	}
+}
+
+// VelocityVerlet is the standard kick-drift-kick form:
+// v += 0.5*a_old*dt; x += v*dt; recompute a_new; v += 0.5*a_new*dt.
+// This replaces the previous leapfrog implementation, which had a subtle
+// bug: it applied the second half-kick to the particle's pre-step velocity
+// instead of the velocity just updated by the first half-kick and drift.
+type VelocityVerlet struct{}
+
+func (VelocityVerlet) Step(p particle, dt float64, f ForceFunc) particle {
+	xVelocity := p.xVelocity + 0.5*dt*p.xAcceleration
+	yVelocity := p.yVelocity + 0.5*dt*p.yAcceleration
+	xPosition := p.xPosition + dt*xVelocity
+	yPosition := p.yPosition + dt*yVelocity
+
+	newForce := f(particle{mass: p.mass, xPosition: xPosition, yPosition: yPosition})
+	ax := newForce.x / p.mass
+	ay := newForce.y / p.mass
+
+	xVelocity += 0.5 * dt * ax
+	yVelocity += 0.5 * dt * ay
+
+	return particle{
+		mass:          p.mass,
+		xPosition:     xPosition,
+		yPosition:     yPosition,
+		xVelocity:     xVelocity,
+		yVelocity:     yVelocity,
+		xAcceleration: ax,
+		yAcceleration: ay,
+	}
+}
+
+// RK4 is the classical 4th-order Runge-Kutta integrator. Unlike the other
+// integrators it needs forces at four sub-steps through the interval, so it
+// evaluates f against intermediate positions and velocities rather than a
+// single precomputed force.
+type RK4 struct{}
+
+// rk4State is the (position, velocity) phase-space vector RK4 integrates.
+type rk4State struct {
+	x, y, vx, vy float64
+}
+
+func (s rk4State) plus(o rk4State, scale float64) rk4State {
+	return rk4State{
+		x:  s.x + o.x*scale,
+		y:  s.y + o.y*scale,
+		vx: s.vx + o.vx*scale,
+		vy: s.vy + o.vy*scale,
+	}
+}
+
+// derivative evaluates ds/dt = (vx, vy, ax, ay) at s.
+func (s rk4State) derivative(mass float64, f ForceFunc) rk4State {
+	force := f(particle{mass: mass, xPosition: s.x, yPosition: s.y, xVelocity: s.vx, yVelocity: s.vy})
+	return rk4State{x: s.vx, y: s.vy, vx: force.x / mass, vy: force.y / mass}
+}
+
+func (RK4) Step(p particle, dt float64, f ForceFunc) particle {
+	s0 := rk4State{x: p.xPosition, y: p.yPosition, vx: p.xVelocity, vy: p.yVelocity}
+
+	k1 := s0.derivative(p.mass, f)
+	k2 := s0.plus(k1, dt/2).derivative(p.mass, f)
+	k3 := s0.plus(k2, dt/2).derivative(p.mass, f)
+	k4 := s0.plus(k3, dt).derivative(p.mass, f)
+
+	next := rk4State{
+		x:  s0.x + dt/6*(k1.x+2*k2.x+2*k3.x+k4.x),
+		y:  s0.y + dt/6*(k1.y+2*k2.y+2*k3.y+k4.y),
+		vx: s0.vx + dt/6*(k1.vx+2*k2.vx+2*k3.vx+k4.vx),
+		vy: s0.vy + dt/6*(k1.vy+2*k2.vy+2*k3.vy+k4.vy),
+	}
+
+	finalForce := f(particle{mass: p.mass, xPosition: next.x, yPosition: next.y, xVelocity: next.vx, yVelocity: next.vy})
+	return particle{
+		mass:          p.mass,
+		xPosition:     next.x,
+		yPosition:     next.y,
+		xVelocity:     next.vx,
+		yVelocity:     next.vy,
+		xAcceleration: finalForce.x / p.mass,
+		yAcceleration: finalForce.y / p.mass,
+	}
+}
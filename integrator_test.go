@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// totalEnergy returns kinetic plus gravitational potential energy. At the
+// separations used below, softening is negligible relative to r^3, so the
+// ordinary Newtonian potential -Gm1m2/r is an accurate approximation of the
+// force law computeForces actually applies.
+func totalEnergy(particles []particle) float64 {
+	energy := 0.0
+	for i, p := range particles {
+		energy += 0.5 * p.mass * (p.xVelocity*p.xVelocity + p.yVelocity*p.yVelocity)
+		for j := i + 1; j < len(particles); j++ {
+			o := particles[j]
+			d := math.Hypot(p.xPosition-o.xPosition, p.yPosition-o.yPosition)
+			energy -= g * p.mass * o.mass / d
+		}
+	}
+	return energy
+}
+
+// TestIntegratorsConserveEnergy runs each integrator on a 2-body circular
+// orbit and asserts the system's energy doesn't drift by more than a small
+// fraction per orbit.
+func TestIntegratorsConserveEnergy(t *testing.T) {
+	const (
+		heavy          = 1e9
+		light          = 1.0
+		r              = 10000.0
+		orbits         = 3.0
+		stepsPerOrbit  = 1000
+		maxDriftPerOrb = 0.01 // 1%
+	)
+
+	integrators := map[string]Integrator{
+		"SymplecticEuler": SymplecticEuler{},
+		"VelocityVerlet":  VelocityVerlet{},
+		"RK4":             RK4{},
+	}
+
+	for name, integrator := range integrators {
+		t.Run(name, func(t *testing.T) {
+			particles := []particle{
+				{mass: heavy},
+				{mass: light, xPosition: r},
+			}
+
+			// circular orbit: centripetal acceleration matches gravity at r
+			fr := computeForces(particles)[1]
+			v := math.Sqrt(math.Hypot(fr.x, fr.y) * r / light)
+			particles[1].yVelocity = v
+			for i, f := range computeForces(particles) {
+				particles[i].xAcceleration = f.x / particles[i].mass
+				particles[i].yAcceleration = f.y / particles[i].mass
+			}
+
+			initial := totalEnergy(particles)
+
+			period := 2 * math.Pi * r / v
+			dt := period / stepsPerOrbit
+			steps := int(orbits * stepsPerOrbit)
+			for s := 0; s < steps; s++ {
+				snapshot := make([]particle, len(particles))
+				copy(snapshot, particles)
+				bodies, prepared := prepareForces(snapshot)
+				for i, p := range snapshot {
+					particles[i] = integrator.Step(p, dt, forceFuncFor(prepared, bodies[i]))
+				}
+			}
+
+			drift := math.Abs((totalEnergy(particles)-initial)/initial) / orbits
+			if drift > maxDriftPerOrb {
+				t.Errorf("%s: energy drift %.4f%% per orbit exceeds %.4f%%", name, drift*100, maxDriftPerOrb*100)
+			}
+		})
+	}
+}
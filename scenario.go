@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParticleSpec is one explicitly-specified initial particle.
+type ParticleSpec struct {
+	Mass float64 `json:"mass" yaml:"mass"`
+	X    float64 `json:"x" yaml:"x"`
+	Y    float64 `json:"y" yaml:"y"`
+	VX   float64 `json:"vx" yaml:"vx"`
+	VY   float64 `json:"vy" yaml:"vy"`
+}
+
+// GeneratorSpec describes a randomized initial condition, used in place of
+// an explicit Particles list.
+type GeneratorSpec struct {
+	Kind  string  `json:"kind" yaml:"kind"` // only "normal" is supported
+	Count int     `json:"count" yaml:"count"`
+	Sigma float64 `json:"sigma" yaml:"sigma"` // std. dev. as a fraction of size
+	Mass  float64 `json:"mass" yaml:"mass"`
+}
+
+// Scenario is a complete, serializable description of a simulation run: the
+// physical constants, which integrator/solver/collision scheme to use, and
+// either an explicit particle list or a generator spec for one. Zero-valued
+// fields fall back to the running defaults, so a scenario only needs to
+// specify what it wants to change.
+type Scenario struct {
+	G          float64        `json:"g" yaml:"g"`
+	Epoch      float64        `json:"epoch" yaml:"epoch"`
+	Size       float64        `json:"size" yaml:"size"`
+	DrawRadius float64        `json:"drawRadius" yaml:"drawRadius"`
+	Seed       int64          `json:"seed" yaml:"seed"` // 0 means unseeded (time-based)
+	Integrator string         `json:"integrator" yaml:"integrator"`
+	Solver     string         `json:"solver" yaml:"solver"` // direct, barnes-hut, auto
+	Collision  string         `json:"collision" yaml:"collision"`
+	Particles  []ParticleSpec `json:"particles" yaml:"particles"`
+	Generator  *GeneratorSpec `json:"generator" yaml:"generator"`
+
+	// FieldMode switches on the scent-field overlay (see field.go); nil
+	// keeps whatever was running before, since false is as meaningful a
+	// setting as true.
+	FieldMode          *bool   `json:"fieldMode" yaml:"fieldMode"`
+	FieldSteerStrength float64 `json:"fieldSteerStrength" yaml:"fieldSteerStrength"`
+}
+
+// integratorsByName maps a Scenario's Integrator field to an Integrator.
+var integratorsByName = map[string]Integrator{
+	"symplectic-euler": SymplecticEuler{},
+	"velocity-verlet":  VelocityVerlet{},
+	"rk4":              RK4{},
+}
+
+// collisionModesByName maps a Scenario's Collision field to a collisionMode.
+var collisionModesByName = map[string]collisionMode{
+	"none":    collisionNone,
+	"elastic": collisionElastic,
+	"merge":   collisionMerge,
+}
+
+// defaultScenario reproduces the simulation's original boot behavior: three
+// normally-distributed, equal-mass bodies under gravity, with today's
+// defaults for every other parameter.
+func defaultScenario() *Scenario {
+	return &Scenario{
+		G:          1,
+		Epoch:      1E-5,
+		Size:       800,
+		DrawRadius: 8,
+		Integrator: "velocity-verlet",
+		Solver:     "auto",
+		Collision:  "elastic",
+		Generator: &GeneratorSpec{
+			Kind:  "normal",
+			Count: defaultParticleCount,
+			Sigma: 1.0 / 6,
+			Mass:  1E7,
+		},
+	}
+}
+
+// ParseScenario decodes a Scenario from data, using ext (e.g. ".json",
+// ".yaml", ".yml") to pick a format.
+func ParseScenario(data []byte, ext string) (*Scenario, error) {
+	var s Scenario
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+	default:
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+// LoadScenarioFile reads and parses a Scenario from path, using its file
+// extension to pick JSON or YAML.
+func LoadScenarioFile(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data, filepath.Ext(path))
+}
+
+// apply installs s as w's simulation parameters and particles, replacing
+// the current integrator, solver, collision mode and particle set. Fields
+// left at their zero value keep whatever was running before. The physics
+// globals were compile-time constants before a Scenario could change them
+// at runtime, and World.step / the per-connection renderers still read them
+// without any synchronization of their own, so every write here happens
+// under w.mu — the same lock that guards the rest of w's state.
+func (s *Scenario) apply(w *World) error {
+	var integrator Integrator
+	if s.Integrator != "" {
+		var ok bool
+		if integrator, ok = integratorsByName[s.Integrator]; !ok {
+			return fmt.Errorf("scenario: unknown integrator %q", s.Integrator)
+		}
+	}
+
+	var mode collisionMode
+	if s.Collision != "" {
+		var ok bool
+		if mode, ok = collisionModesByName[s.Collision]; !ok {
+			return fmt.Errorf("scenario: unknown collision mode %q", s.Collision)
+		}
+	}
+
+	switch s.Solver {
+	case "", "auto", "direct", "barnes-hut":
+	default:
+		return fmt.Errorf("scenario: unknown solver %q", s.Solver)
+	}
+
+	w.mu.Lock()
+	if s.G != 0 {
+		g = s.G
+	}
+	if s.Epoch != 0 {
+		epoch = s.Epoch
+	}
+	if s.Size != 0 {
+		size = s.Size
+	}
+	if s.DrawRadius != 0 {
+		drawRadius = s.DrawRadius
+	}
+	if s.Integrator != "" {
+		activeIntegrator = integrator
+	}
+	if s.Collision != "" {
+		activeCollisionMode = mode
+	}
+	switch s.Solver {
+	case "", "auto":
+		solverOverride = ""
+	default:
+		solverOverride = s.Solver
+	}
+	if s.FieldMode != nil {
+		fieldMode = *s.FieldMode
+	}
+	if s.FieldSteerStrength != 0 {
+		fieldSteerStrength = s.FieldSteerStrength
+	}
+	// initialParticles reads size (e.g. to scale a generator's sigma), so it
+	// needs to run after size is updated above but still inside the lock.
+	particles, err := s.initialParticles()
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	w.reset(particles)
+	return nil
+}
+
+// initialParticles returns s's explicit Particles list, or a freshly
+// generated one from Generator. A seed of 0 means unseeded: the generator
+// draws from a time-seeded source, matching the original nondeterministic
+// boot behavior.
+func (s *Scenario) initialParticles() ([]particle, error) {
+	if len(s.Particles) > 0 {
+		particles := make([]particle, len(s.Particles))
+		for i, spec := range s.Particles {
+			particles[i] = particle{
+				mass:      spec.Mass,
+				xPosition: spec.X,
+				yPosition: spec.Y,
+				xVelocity: spec.VX,
+				yVelocity: spec.VY,
+			}
+		}
+		return particles, nil
+	}
+
+	gen := s.Generator
+	if gen == nil {
+		gen = &GeneratorSpec{Kind: "normal", Count: defaultParticleCount, Sigma: 1.0 / 6}
+	}
+	if gen.Kind != "normal" {
+		return nil, fmt.Errorf("scenario: unknown generator kind %q", gen.Kind)
+	}
+
+	seed := s.Seed
+	if seed == 0 {
+		seed = time.Now().UTC().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	mass := gen.Mass
+	if mass == 0 {
+		mass = m
+	}
+
+	particles := make([]particle, gen.Count)
+	for i := range particles {
+		particles[i] = particle{
+			mass:      mass,
+			xPosition: rng.NormFloat64() * size * gen.Sigma,
+			yPosition: rng.NormFloat64() * size * gen.Sigma,
+		}
+	}
+	return particles, nil
+}
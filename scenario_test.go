@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"testing"
+)
+
+// hashParticles hashes the particles' positions and velocities, giving a
+// compact fingerprint of the simulation's state.
+func hashParticles(particles []particle) uint64 {
+	h := fnv.New64a()
+	for _, p := range particles {
+		fmt.Fprintf(h, "%.9f,%.9f,%.9f,%.9f,%.9f;", p.mass, p.xPosition, p.yPosition, p.xVelocity, p.yVelocity)
+	}
+	return h.Sum64()
+}
+
+// runScenario loads path, steps it n times, and returns the resulting
+// particle state.
+func runScenario(t *testing.T, path string, n int) []particle {
+	t.Helper()
+	scenario, err := LoadScenarioFile(path)
+	if err != nil {
+		t.Fatalf("LoadScenarioFile(%q): %v", path, err)
+	}
+
+	w := &World{}
+	if err := scenario.apply(w); err != nil {
+		t.Fatalf("apply(%q): %v", path, err)
+	}
+	for i := 0; i < n; i++ {
+		w.step()
+	}
+	return w.snapshot()
+}
+
+// TestCanonicalScenariosAreDeterministic runs each canonical scenario twice
+// from scratch and asserts the resulting particle state hashes match,
+// exercising the determinism an explicit particle list plus a fixed
+// integrator is supposed to guarantee.
+func TestCanonicalScenariosAreDeterministic(t *testing.T) {
+	const steps = 500
+
+	for _, path := range []string{
+		"testdata/figure-eight.json",
+		"testdata/pythagorean.json",
+		"testdata/sun-earth-moon.json",
+	} {
+		t.Run(path, func(t *testing.T) {
+			first := hashParticles(runScenario(t, path, steps))
+			second := hashParticles(runScenario(t, path, steps))
+			if first != second {
+				t.Fatalf("%s: non-deterministic replay: %x != %x", path, first, second)
+			}
+		})
+	}
+}
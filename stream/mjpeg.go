@@ -0,0 +1,51 @@
+// Package stream provides pure-Go HTTP transports for serving a live
+// sequence of JPEG frames to a browser, as an alternative to piping frames
+// through an ffmpeg subprocess.
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+)
+
+const boundary = "frame"
+
+// MJPEGWriter writes a multipart/x-mixed-replace stream of JPEG frames to an
+// http.ResponseWriter, consumable directly by an <img> tag in a browser.
+type MJPEGWriter struct {
+	w http.ResponseWriter
+}
+
+// NewMJPEGWriter sets the response headers for a multipart/x-mixed-replace
+// stream and returns a writer for pushing frames to it.
+func NewMJPEGWriter(w http.ResponseWriter) *MJPEGWriter {
+	w.Header().Set("Content-Type", fmt.Sprintf("multipart/x-mixed-replace; boundary=%s", boundary))
+	return &MJPEGWriter{w: w}
+}
+
+// WriteFrame encodes img as JPEG and writes it as the next part of the
+// stream, flushing so the browser renders it immediately.
+func (m *MJPEGWriter) WriteFrame(img image.Image) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(m.w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", boundary, buf.Len()); err != nil {
+		return err
+	}
+	if _, err := m.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(m.w, "\r\n"); err != nil {
+		return err
+	}
+
+	if f, ok := m.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// upgrader allows any origin: this is a local development server with no
+// cross-site credentials to protect.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WebSocketFrameWriter pushes binary JPEG frames over a websocket
+// connection, one per call to WriteFrame.
+type WebSocketFrameWriter struct {
+	conn *websocket.Conn
+}
+
+// NewWebSocketFrameWriter upgrades the HTTP connection to a websocket and
+// returns a writer for pushing frames over it.
+func NewWebSocketFrameWriter(w http.ResponseWriter, r *http.Request) (*WebSocketFrameWriter, error) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &WebSocketFrameWriter{conn: conn}, nil
+}
+
+// WriteFrame encodes img as JPEG and sends it as a single binary message.
+func (w *WebSocketFrameWriter) WriteFrame(img image.Image) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		return err
+	}
+	return w.conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+}
+
+// Close closes the underlying websocket connection.
+func (w *WebSocketFrameWriter) Close() error {
+	return w.conn.Close()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// uiHandler serves a small page that displays the live MJPEG stream and lets
+// the viewer spawn particles by clicking (or click-dragging, to also set an
+// initial velocity) on it.
+func uiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(uiHTML))
+}
+
+// spawnRequest is the body POSTed to /3body/spawn.
+type spawnRequest struct {
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	VX   float64 `json:"vx"`
+	VY   float64 `json:"vy"`
+	Mass float64 `json:"mass"`
+}
+
+// spawnHandler adds a new particle to the shared world at the given
+// position, velocity and mass. Mass defaults to m when omitted or zero.
+func spawnHandler(w http.ResponseWriter, r *http.Request) {
+	var req spawnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mass := req.Mass
+	if mass == 0 {
+		mass = m
+	}
+	world.spawn(particle{
+		mass:      mass,
+		xPosition: req.X,
+		yPosition: req.Y,
+		xVelocity: req.VX,
+		yVelocity: req.VY,
+	})
+}
+
+// clearHandler removes every particle from the shared world.
+func clearHandler(w http.ResponseWriter, r *http.Request) {
+	world.clear()
+}
+
+// statsResponse is the body returned by statsHandler.
+type statsResponse struct {
+	Active int `json:"active"`
+	Merged int `json:"merged"`
+}
+
+// statsHandler reports the running count of active vs. merged bodies that
+// simulationStats tracks.
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	active, merged := stats.get()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statsResponse{Active: active, Merged: merged})
+}
+
+// scenarioHandler replaces the shared world's parameters and particles with
+// the JSON-encoded Scenario in the request body.
+func scenarioHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scenario, err := ParseScenario(data, ".json")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := scenario.apply(world); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+const uiHTML = `<!DOCTYPE html>
+<html>
+<head><title>3body</title></head>
+<body style="margin:0;background:#111">
+<img id="stream" src="/3body?transport=mjpeg" width="800" height="800">
+<button id="clear" style="position:absolute;top:8px;left:8px">clear</button>
+<span id="stats" style="position:absolute;top:8px;left:60px;color:#ccc;font:12px monospace"></span>
+<script>
+var img = document.getElementById("stream");
+var clearButton = document.getElementById("clear");
+var statsSpan = document.getElementById("stats");
+var drag = null;
+
+function pollStats() {
+	fetch("/3body/stats").then(function(r) { return r.json(); }).then(function(s) {
+		statsSpan.textContent = "active: " + s.active + "  merged: " + s.merged;
+	});
+}
+setInterval(pollStats, 1000);
+pollStats();
+
+function particleAt(e) {
+	var rect = img.getBoundingClientRect();
+	return {x: e.clientX - rect.left - rect.width/2, y: e.clientY - rect.top - rect.height/2};
+}
+
+img.addEventListener("mousedown", function(e) {
+	drag = particleAt(e);
+});
+
+img.addEventListener("mouseup", function(e) {
+	if (!drag) return;
+	var release = particleAt(e);
+	fetch("/3body/spawn", {
+		method: "POST",
+		body: JSON.stringify({
+			x: drag.x,
+			y: drag.y,
+			vx: (drag.x - release.x) * 5,
+			vy: (drag.y - release.y) * 5,
+		}),
+	});
+	drag = null;
+});
+
+clearButton.addEventListener("click", function() {
+	fetch("/3body/clear", {method: "POST"});
+});
+</script>
+</body>
+</html>
+`
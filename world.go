@@ -0,0 +1,120 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// World holds the live set of particles. The simulation goroutine and the
+// HTTP handlers that spawn or clear particles all touch it concurrently, so
+// access is guarded by mu.
+type World struct {
+	mu        sync.Mutex
+	particles []particle
+	field     Field
+}
+
+// world is the single shared simulation every viewer renders from.
+var world = newWorld()
+
+func newWorld() *World {
+	w := &World{}
+	if err := defaultScenario().apply(w); err != nil {
+		log.Fatal(err)
+	}
+	return w
+}
+
+// reset replaces w's particles wholesale, seeding their leapfrog
+// accelerations and clearing the scent field. Used when a Scenario loads.
+func (w *World) reset(particles []particle) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, f := range computeForces(particles) {
+		particles[i].xAcceleration = f.x
+		particles[i].yAcceleration = f.y
+	}
+	w.particles = particles
+	w.field = Field{}
+	stats.setActive(len(particles))
+}
+
+// snapshot returns a copy of the current particles, safe for a renderer to
+// read without holding w.mu.
+func (w *World) snapshot() []particle {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	particles := make([]particle, len(w.particles))
+	copy(particles, w.particles)
+	return particles
+}
+
+// fieldSnapshot returns a copy of the current scent field, safe for a
+// renderer to read without holding w.mu.
+func (w *World) fieldSnapshot() Field {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.field
+}
+
+// renderParams returns the Scenario-tunable globals a renderer needs (size,
+// drawRadius, fieldMode), read under w.mu so they can't tear against a
+// concurrent Scenario.apply.
+func (w *World) renderParams() (sz, radius float64, fieldOn bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return size, drawRadius, fieldMode
+}
+
+// spawn appends a new particle to the world, e.g. in response to a click in
+// the browser UI.
+func (w *World) spawn(p particle) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.particles = append(w.particles, p)
+}
+
+// clear removes every particle from the world.
+func (w *World) clear() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.particles = nil
+}
+
+// step advances the world by one epoch: compute forces, integrate, then
+// resolve any collisions.
+func (w *World) step() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	snapshot := make([]particle, len(w.particles))
+	copy(snapshot, w.particles)
+
+	w.field.Decay()
+	for _, p := range snapshot {
+		w.field.Deposit(p.xPosition, p.yPosition, fieldDepositAmount)
+	}
+
+	bodies, prepared := prepareForces(snapshot)
+
+	updated := make([]particle, len(snapshot))
+	for i, p := range snapshot {
+		updated[i] = activeIntegrator.Step(p, epoch, forceFuncFor(prepared, bodies[i]))
+		if fieldMode {
+			gx, gy := w.field.Gradient(updated[i].xPosition, updated[i].yPosition)
+			updated[i].xVelocity += fieldSteerStrength * gx
+			updated[i].yVelocity += fieldSteerStrength * gy
+		}
+	}
+	w.particles = resolveCollisions(updated)
+	stats.setActive(len(w.particles))
+}
+
+// runSimulation steps w forever; it's meant to run in its own goroutine for
+// the lifetime of the process.
+func runSimulation(w *World) {
+	for {
+		w.step()
+	}
+}